@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleDebugPeers dumps current peers, rooms, keepalive ages, and
+// goroutine counts, gated behind the same operator token as WS moderation.
+func (s *SnapdropServer) handleDebugPeers(c *gin.Context) {
+	if authenticateRole(c.Request) != RoleOperator {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "operator token required"})
+		return
+	}
+
+	rooms := s.store.Rooms()
+	roomDump := make([]map[string]interface{}, 0, len(rooms))
+
+	for room, count := range rooms {
+		peers := s.store.Peers(room)
+		peerDump := make([]map[string]interface{}, 0, len(peers))
+
+		for _, peer := range peers {
+			info := peer.getInfo()
+			info["role"] = peer.Role
+			if !peer.LastBeat.IsZero() {
+				info["lastBeatAgeSeconds"] = time.Since(peer.LastBeat).Seconds()
+			}
+			peerDump = append(peerDump, info)
+		}
+
+		roomDump = append(roomDump, map[string]interface{}{
+			"room":      room,
+			"kind":      "ip",
+			"peerCount": count,
+			"peers":     peerDump,
+		})
+	}
+
+	s.mutex.RLock()
+	for code, room := range s.codedRooms {
+		peerDump := make([]map[string]interface{}, 0, len(room.Peers))
+		for _, peer := range room.Peers {
+			info := peer.getInfo()
+			info["role"] = peer.Role
+			if !peer.LastBeat.IsZero() {
+				info["lastBeatAgeSeconds"] = time.Since(peer.LastBeat).Seconds()
+			}
+			peerDump = append(peerDump, info)
+		}
+		roomDump = append(roomDump, map[string]interface{}{
+			"room":      code,
+			"kind":      "coded",
+			"peerCount": len(room.Peers),
+			"peers":     peerDump,
+		})
+	}
+	s.mutex.RUnlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"rooms":      roomDump,
+		"goroutines": runtime.NumGoroutine(),
+	})
+}
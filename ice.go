@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// ICEServer mirrors the RTCIceServer dictionary the browser's
+// RTCPeerConnection constructor expects.
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// iceConfig caches the parsed ICE server list and reloads it from disk on
+// SIGHUP so operators can rotate TURN credentials without a restart.
+type iceConfig struct {
+	once sync.Once
+	mu   sync.RWMutex
+	path string
+	list []ICEServer
+}
+
+var globalICEConfig = &iceConfig{}
+
+// init loads the config for the first time and starts the SIGHUP watcher.
+// Safe to call multiple times; only the first call has any effect.
+func (c *iceConfig) init(path string) {
+	c.once.Do(func() {
+		c.path = path
+		c.reload()
+		c.watchSIGHUP()
+	})
+}
+
+func (c *iceConfig) reload() {
+	if c.path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		log.Printf("ice config: failed to read %s: %v", c.path, err)
+		return
+	}
+
+	var list []ICEServer
+	if err := json.Unmarshal(data, &list); err != nil {
+		log.Printf("ice config: failed to parse %s: %v", c.path, err)
+		return
+	}
+
+	c.mu.Lock()
+	c.list = list
+	c.mu.Unlock()
+
+	log.Printf("ice config: loaded %d server(s) from %s", len(list), c.path)
+}
+
+func (c *iceConfig) watchSIGHUP() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Println("ice config: SIGHUP received, reloading")
+			c.reload()
+		}
+	}()
+}
+
+// servers returns a copy of the current ICE server list so callers can't
+// mutate the cached slice.
+func (c *iceConfig) servers() []ICEServer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := make([]ICEServer, len(c.list))
+	copy(out, c.list)
+	return out
+}
+
+// resolveICEConfigPath returns the ICE_CONFIG env var, used as a fallback
+// when the -ice-config flag isn't set.
+func resolveICEConfigPath() string {
+	return os.Getenv("ICE_CONFIG")
+}
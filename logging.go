@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type ctxKey string
+
+const (
+	ctxKeyPeerID ctxKey = "peer_id"
+	ctxKeyPeerIP ctxKey = "peer_ip"
+	ctxKeyRoom   ctxKey = "room"
+	ctxKeyConnID ctxKey = "conn_id"
+)
+
+var logger = newLogger()
+
+// newLogger builds the process-wide structured logger: JSON when
+// LOG_FORMAT=json, human-readable text otherwise, at the level named by
+// LOG_LEVEL (debug|info|warn|error, default info).
+func newLogger() *slog.Logger {
+	opts := &slog.HandlerOptions{Level: resolveLogLevel()}
+
+	var handler slog.Handler
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), "json") {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+
+	return slog.New(handler)
+}
+
+func resolveLogLevel() slog.Level {
+	switch strings.ToLower(os.Getenv("LOG_LEVEL")) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// peerContext builds the base logging context for a newly connected peer.
+func peerContext(peerID, peerIP, connID string) context.Context {
+	ctx := context.WithValue(context.Background(), ctxKeyPeerID, peerID)
+	ctx = context.WithValue(ctx, ctxKeyPeerIP, peerIP)
+	ctx = context.WithValue(ctx, ctxKeyConnID, connID)
+	return ctx
+}
+
+// withRoom returns a copy of ctx annotated with the given room.
+func withRoom(ctx context.Context, room string) context.Context {
+	return context.WithValue(ctx, ctxKeyRoom, room)
+}
+
+// logFrom returns a logger carrying whatever context keys are present on
+// ctx, plus any extra slog key/value pairs (e.g. "msg_type", msgType).
+func logFrom(ctx context.Context, extra ...any) *slog.Logger {
+	l := logger
+	for _, key := range []ctxKey{ctxKeyPeerID, ctxKeyPeerIP, ctxKeyRoom, ctxKeyConnID} {
+		if v := ctx.Value(key); v != nil {
+			l = l.With(string(key), v)
+		}
+	}
+	if len(extra) > 0 {
+		l = l.With(extra...)
+	}
+	return l
+}
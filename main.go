@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -25,7 +29,16 @@ type Peer struct {
 	RTCSupported bool
 	Name         PeerName
 	LastBeat     time.Time
-	TimerID      *time.Timer
+	RoomCodes    []string // coded rooms this peer has joined, in addition to its IP room
+	Role         Role
+	Ctx          context.Context // carries peer_id/peer_ip/room/conn_id for structured logging
+	storeUnsub   func()          // stops the RoomStore from delivering to this peer
+
+	writeCh     chan interface{} // outbound messages for this peer's writePump
+	kill        chan struct{}    // closed to force this peer's writePump to tear down the socket
+	killOnce    sync.Once
+	closeCode   int    // WS close code to send when kill fires, set by the first killPeer call
+	closeReason string // human-readable reason paired with closeCode
 }
 
 type PeerName struct {
@@ -38,22 +51,21 @@ type PeerName struct {
 }
 
 type SnapdropServer struct {
-	rooms    map[string]map[string]*Peer
-	upgrader websocket.Upgrader
-	mutex    sync.RWMutex
-	done     chan struct{}    // For cleanup signaling
-	wg       sync.WaitGroup   // For graceful shutdown
+	store        RoomStore
+	codedRooms   map[string]*codedRoom
+	roomCodeTTL  time.Duration
+	mutedRooms   map[string]bool
+	upgrader     websocket.Upgrader
+	mutex        sync.RWMutex
+	done         chan struct{}  // For cleanup signaling
+	wg           sync.WaitGroup // For graceful shutdown
+	shuttingDown atomic.Bool    // true once Shutdown has started rejecting new connections
 }
 
 func main() {
-	// Handle OS signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-sigChan
-		fmt.Println("Shutdown signal received, exiting...")
-		os.Exit(0)
-	}()
+	iceConfigPath := flag.String("ice-config", resolveICEConfigPath(), "path to a JSON file of ICE (STUN/TURN) server entries")
+	flag.Parse()
+	globalICEConfig.init(*iceConfigPath)
 
 	// Rate limiter setup
 	rate := limiter.Rate{
@@ -71,8 +83,16 @@ func main() {
 	router.Use(gin.Recovery())
 	router.Use(rateLimitMiddleware(rateLimiter))
 
+	roomStore, err := newRoomStore()
+	if err != nil {
+		log.Fatalf("failed to initialize room store: %v", err)
+	}
+
 	server := &SnapdropServer{
-		rooms: make(map[string]map[string]*Peer),
+		store:       roomStore,
+		codedRooms:  make(map[string]*codedRoom),
+		roomCodeTTL: resolveRoomCodeTTL(),
+		mutedRooms:  make(map[string]bool),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true
@@ -80,20 +100,24 @@ func main() {
 		},
 		done: make(chan struct{}),
 	}
-
-	// Graceful shutdown handler
-	go func() {
-		<-sigChan
-		fmt.Println("Shutdown signal received, cleaning up...")
-		close(server.done)
-		server.wg.Wait()
-		os.Exit(0)
-	}()
+	go server.runRoomJanitor()
 
 	// WebSocket endpoints
 	router.GET("/ws", server.handleWebSocket)
 	router.GET("/server/webrtc", server.handleWebSocket)
 
+	// ICE/TURN server configuration
+	router.GET("/ice-servers", server.handleICEServers)
+
+	// Coded rooms
+	router.POST("/rooms", server.handleCreateRoom)
+
+	// Operator diagnostics
+	router.GET("/debug/peers", server.handleDebugPeers)
+
+	// Prometheus metrics
+	router.GET("/metrics", server.handleMetrics)
+
 	// Serve static files
 	router.StaticFile("/", "./public/index.html")
 	router.StaticFile("/service-worker.js", "./public/service-worker.js")
@@ -110,15 +134,35 @@ func main() {
 
 	// Start server
 	var addr string
-	if len(os.Args) > 1 && os.Args[1] == "public" {
+	if args := flag.Args(); len(args) > 0 && args[0] == "public" {
 		addr = ":" + port
 	} else {
 		addr = "0.0.0.0:" + port
 	}
 
-	log.Printf("Snapdrop is running on port %s", port)
-	if err := router.Run(addr); err != nil {
-		log.Fatal(err)
+	httpServer := &http.Server{Addr: addr, Handler: router}
+
+	go func() {
+		log.Printf("Snapdrop is running on port %s", port)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+	fmt.Println("Shutdown signal received, draining connections...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveShutdownTimeout())
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("graceful shutdown did not finish before timeout: %v", err)
+	}
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("http server shutdown error: %v", err)
 	}
 }
 
@@ -142,29 +186,54 @@ func rateLimitMiddleware(limiter *limiter.Limiter) gin.HandlerFunc {
 	}
 }
 
+func (s *SnapdropServer) handleICEServers(c *gin.Context) {
+	c.JSON(http.StatusOK, globalICEConfig.servers())
+}
+
 func (s *SnapdropServer) handleWebSocket(c *gin.Context) {
+	if s.shuttingDown.Load() {
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+		return
+	}
+
+	connID := generateUUID()
+
 	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Printf("Failed to upgrade connection: %v", err)
+		wsUpgradeFailuresTotal.Inc()
+		logFrom(peerContext("", "", connID)).Error("websocket upgrade failed", "error", err)
 		return
 	}
 
-	if ip := c.ClientIP(); ip == "" {
-		log.Println("Invalid client IP")
+	ip := c.ClientIP()
+	if ip == "" {
+		logFrom(peerContext("", "", connID)).Error("rejected connection with empty client IP")
 		conn.Close()
 		return
 	}
 
+	peerID := generateUUID()
+	ctx := withRoom(peerContext(peerID, ip, connID), ip)
+
 	s.wg.Add(1)
 	peer := &Peer{
-		ID:           generateUUID(),
-		IP:           c.ClientIP(),
+		ID:           peerID,
+		IP:           ip,
 		Socket:       conn,
 		RTCSupported: strings.Contains(c.Request.URL.Path, "webrtc"),
 		LastBeat:     time.Now(),
+		Role:         authenticateRole(c.Request),
+		Ctx:          ctx,
+		writeCh:      make(chan interface{}, resolveSendBufferSize()),
+		kill:         make(chan struct{}),
 	}
 
 	peer.setName(c.Request.Header.Get("User-Agent"))
+	logFrom(peer.Ctx).Info("peer connected", "role", peer.Role)
+
+	s.wg.Add(1)
+	go s.writePump(peer)
+
 	s.onConnection(peer)
 
 	// Handle incoming messages
@@ -172,9 +241,7 @@ func (s *SnapdropServer) handleWebSocket(c *gin.Context) {
 }
 
 func (s *SnapdropServer) onConnection(peer *Peer) {
-	s.mutex.Lock()
 	s.joinRoom(peer)
-	s.mutex.Unlock()
 
 	// Send display name
 	s.send(peer, map[string]interface{}{
@@ -183,6 +250,7 @@ func (s *SnapdropServer) onConnection(peer *Peer) {
 			"displayName": peer.Name.DisplayName,
 			"deviceName":  peer.Name.DeviceName,
 		},
+		"iceServers": globalICEConfig.servers(),
 	})
 
 	s.keepAlive(peer)
@@ -199,11 +267,14 @@ func (s *SnapdropServer) handleMessages(peer *Peer) {
 		default:
 			messageType, message, err := peer.Socket.ReadMessage()
 			if err != nil {
-				if !websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
-					log.Printf("Read error: %v", err)
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					keepAliveTimeoutsTotal.Inc()
+				} else if !websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+					logFrom(peer.Ctx).Error("websocket read failed", "error", err)
 				}
 				return
 			}
+			peer.Socket.SetReadDeadline(time.Now().Add(pongWait))
 
 			if messageType != websocket.TextMessage {
 				continue
@@ -211,7 +282,7 @@ func (s *SnapdropServer) handleMessages(peer *Peer) {
 
 			var msg map[string]interface{}
 			if err := json.Unmarshal(message, &msg); err != nil {
-				log.Printf("JSON parse error: %v", err)
+				logFrom(peer.Ctx).Warn("failed to parse incoming message", "error", err)
 				continue
 			}
 
@@ -219,12 +290,25 @@ func (s *SnapdropServer) handleMessages(peer *Peer) {
 			if !ok {
 				continue
 			}
+			logFrom(peer.Ctx, "msg_type", msgType).Debug("received message")
 
 			switch msgType {
 			case "disconnect":
 				return
 			case "pong":
 				peer.LastBeat = time.Now()
+			case "create-room":
+				s.handleCreateRoomMessage(peer, msg)
+			case "join-room":
+				s.handleJoinRoomMessage(peer, msg)
+			case "leave-room":
+				s.handleLeaveRoomMessage(peer, msg)
+			case "kick":
+				s.handleKickMessage(peer, msg)
+			case "mute-room":
+				s.handleMuteRoomMessage(peer, msg)
+			case "list-rooms":
+				s.handleListRoomsMessage(peer, msg)
 			default:
 				s.handleRelayMessage(peer, msg)
 			}
@@ -239,24 +323,55 @@ func (s *SnapdropServer) handleRelayMessage(peer *Peer, msg map[string]interface
 	}
 
 	s.mutex.RLock()
-	recipient, exists := s.rooms[peer.IP][to]
+	muted := s.mutedRooms[peer.IP]
+	if !muted {
+		for _, code := range peer.RoomCodes {
+			if s.mutedRooms[code] {
+				muted = true
+				break
+			}
+		}
+	}
 	s.mutex.RUnlock()
 
-	if exists {
-		delete(msg, "to")
-		msg["sender"] = peer.ID
+	if muted && peer.Role != RoleOperator {
+		s.sendPermissionDenied(peer, "relay")
+		return
+	}
+
+	delete(msg, "to")
+	msg["sender"] = peer.ID
+
+	msgType, _ := msg["type"].(string)
+	messagesRelayedTotal.WithLabelValues(msgType).Inc()
+	if encoded, err := json.Marshal(msg); err == nil {
+		messageSizeBytes.Observe(float64(len(encoded)))
+	}
+
+	logCtx := logFrom(peer.Ctx, "msg_type", msg["type"])
+
+	if recipient, exists := s.store.Lookup(peer.IP, to); exists {
+		logCtx.Debug("relaying message to local peer", "to", to)
 		s.send(recipient, msg)
+		return
 	}
+
+	if recipient, exists := s.lookupCodedPeer(peer, to); exists {
+		logCtx.Debug("relaying message to coded-room peer", "to", to)
+		s.send(recipient, msg)
+		return
+	}
+
+	// Not connected to this instance; let the store fan it out if it can.
+	logCtx.Debug("relaying message via store", "to", to)
+	s.store.Relay(peer.IP, to, msg)
 }
 
 func (s *SnapdropServer) joinRoom(peer *Peer) {
-	// Create room if it doesn't exist
-	if s.rooms[peer.IP] == nil {
-		s.rooms[peer.IP] = make(map[string]*Peer)
-	}
+	existing := s.store.Join(peer.IP, peer)
 
 	// Notify other peers
-	for _, otherPeer := range s.rooms[peer.IP] {
+	for _, otherPeer := range existing {
 		s.send(otherPeer, map[string]interface{}{
 			"type": "peer-joined",
 			"peer": peer.getInfo(),
@@ -264,8 +379,8 @@ func (s *SnapdropServer) joinRoom(peer *Peer) {
 	}
 
 	// Send existing peers to new peer
-	otherPeers := make([]map[string]interface{}, 0)
-	for _, otherPeer := range s.rooms[peer.IP] {
+	otherPeers := make([]map[string]interface{}, 0, len(existing))
+	for _, otherPeer := range existing {
 		otherPeers = append(otherPeers, otherPeer.getInfo())
 	}
 	s.send(peer, map[string]interface{}{
@@ -273,63 +388,71 @@ func (s *SnapdropServer) joinRoom(peer *Peer) {
 		"peers": otherPeers,
 	})
 
-	// Add peer to room
-	s.rooms[peer.IP][peer.ID] = peer
+	peer.storeUnsub = s.store.Subscribe(peer, func(message map[string]interface{}) {
+		s.send(peer, message)
+	})
+
+	peersConnected.WithLabelValues("ip").Inc()
+	if len(existing) == 0 {
+		// First peer in this IP room; no full store scan needed to know
+		// the room count just grew by one.
+		roomsActive.Inc()
+	}
 }
 
 func (s *SnapdropServer) leaveRoom(peer *Peer) {
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	for _, code := range append([]string(nil), peer.RoomCodes...) {
+		s.leaveCodedRoomLocked(peer, code)
+	}
+	s.mutex.Unlock()
 
-	if s.rooms[peer.IP] == nil || s.rooms[peer.IP][peer.ID] == nil {
+	if _, exists := s.store.Lookup(peer.IP, peer.ID); !exists {
 		return
 	}
 
-	s.cancelKeepAlive(peer)
-	delete(s.rooms[peer.IP], peer.ID)
-	peer.Socket.Close()
-
-	if len(s.rooms[peer.IP]) == 0 {
-		delete(s.rooms, peer.IP)
-	} else {
-		for _, otherPeer := range s.rooms[peer.IP] {
-			s.send(otherPeer, map[string]interface{}{
-				"type":   "peer-left",
-				"peerId": peer.ID,
-			})
-		}
+	if peer.storeUnsub != nil {
+		peer.storeUnsub()
+		peer.storeUnsub = nil
 	}
-}
 
-func (s *SnapdropServer) send(peer *Peer, message interface{}) {
-	if peer == nil || peer.Socket == nil {
-		return
+	remaining := s.store.Leave(peer.IP, peer.ID)
+	peer.killPeer(websocket.CloseNormalClosure, "")
+	peersConnected.WithLabelValues("ip").Dec()
+	if len(remaining) == 0 {
+		// Room just emptied out; no full store scan needed to know the
+		// room count just shrank by one.
+		roomsActive.Dec()
 	}
-	
-	err := peer.Socket.WriteJSON(message)
-	if err != nil {
-		log.Printf("Send error: %v", err)
+	logFrom(peer.Ctx).Info("peer left")
+
+	for _, otherPeer := range remaining {
+		s.send(otherPeer, map[string]interface{}{
+			"type":   "peer-left",
+			"peerId": peer.ID,
+		})
 	}
 }
 
-func (s *SnapdropServer) keepAlive(peer *Peer) {
-	s.cancelKeepAlive(peer)
-	timeout := 30 * time.Second
-
-	if time.Since(peer.LastBeat) > 2*timeout {
-		s.leaveRoom(peer)
+// send pushes message onto peer's writeCh for its writePump to deliver.
+// It never blocks: if the channel is already full the peer is treated as
+// slow/dead and torn down instead of backing up the caller.
+func (s *SnapdropServer) send(peer *Peer, message interface{}) {
+	if peer == nil || peer.writeCh == nil {
 		return
 	}
 
-	s.send(peer, map[string]string{"type": "ping"})
-	peer.TimerID = time.AfterFunc(timeout, func() {
-		s.keepAlive(peer)
-	})
+	select {
+	case peer.writeCh <- message:
+	default:
+		logFrom(peer.Ctx).Warn("write buffer full, dropping slow/dead peer")
+		peer.killPeer(websocket.CloseTryAgainLater, "write buffer full")
+	}
 }
 
-func (s *SnapdropServer) cancelKeepAlive(peer *Peer) {
-	if peer != nil && peer.TimerID != nil {
-		peer.TimerID.Stop()
-		peer.TimerID = nil
-	}
+// keepAlive arms the read deadline that detects a dead connection; pings
+// are sent periodically by writePump, and any successful read pushes the
+// deadline back out (see handleMessages).
+func (s *SnapdropServer) keepAlive(peer *Peer) {
+	peer.Socket.SetReadDeadline(time.Now().Add(pongWait))
 }
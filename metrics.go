@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	peersConnected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "snapdrop_peers_connected",
+		Help: "Number of peers currently connected, by room kind.",
+	}, []string{"room_kind"})
+
+	roomsActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "snapdrop_rooms_active",
+		Help: "Number of active rooms, across IP and coded rooms.",
+	})
+
+	messagesRelayedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "snapdrop_messages_relayed_total",
+		Help: "Total number of messages relayed between peers, by message type.",
+	}, []string{"msg_type"})
+
+	wsUpgradeFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "snapdrop_ws_upgrade_failures_total",
+		Help: "Total number of failed WebSocket upgrade attempts.",
+	})
+
+	keepAliveTimeoutsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "snapdrop_keepalive_timeouts_total",
+		Help: "Total number of peers dropped for going quiet past the read deadline.",
+	})
+
+	sendErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "snapdrop_send_errors_total",
+		Help: "Total number of errors writing a message to a peer's socket.",
+	})
+
+	messageSizeBytes = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "snapdrop_message_size_bytes",
+		Help:    "Size in bytes of relayed message payloads.",
+		Buckets: prometheus.ExponentialBuckets(64, 2, 10),
+	})
+)
+
+// metricsRequireAuth reports whether /metrics should be gated behind the
+// operator token, configured via METRICS_REQUIRE_AUTH. Off by default since
+// most Prometheus scrape configs don't attach a bearer token.
+func metricsRequireAuth() bool {
+	return strings.EqualFold(os.Getenv("METRICS_REQUIRE_AUTH"), "true")
+}
+
+func (s *SnapdropServer) handleMetrics(c *gin.Context) {
+	if metricsRequireAuth() && authenticateRole(c.Request) != RoleOperator {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "operator token required"})
+		return
+	}
+	promhttp.Handler().ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,96 @@
+package main
+
+import "github.com/gorilla/websocket"
+
+func (s *SnapdropServer) handleKickMessage(peer *Peer, msg map[string]interface{}) {
+	if !s.requireRole(peer, RoleOperator) {
+		s.sendPermissionDenied(peer, "kick")
+		return
+	}
+
+	targetID, ok := msg["peerId"].(string)
+	if !ok {
+		return
+	}
+
+	reason, _ := msg["reason"].(string)
+	if reason == "" {
+		reason = "Removed by operator"
+	}
+
+	target, exists := s.store.Lookup(peer.IP, targetID)
+	if !exists {
+		target, exists = s.lookupCodedPeer(peer, targetID)
+	}
+	if !exists {
+		return
+	}
+
+	// Route the close through target's own writePump (the only goroutine
+	// allowed to touch its socket) instead of writing a control frame here.
+	// killPeer carries the close code/reason through to the WS close frame
+	// writePump sends before tearing the socket down.
+	s.send(target, map[string]interface{}{"type": "kicked", "reason": reason})
+	target.killPeer(websocket.CloseNormalClosure, reason)
+}
+
+func (s *SnapdropServer) handleMuteRoomMessage(peer *Peer, msg map[string]interface{}) {
+	if !s.requireRole(peer, RoleOperator) {
+		s.sendPermissionDenied(peer, "mute-room")
+		return
+	}
+
+	// A coded room is muted by its code; otherwise default to the
+	// operator's own IP room.
+	room := peer.IP
+	if code, ok := msg["code"].(string); ok && code != "" {
+		room = code
+	}
+
+	muted := true
+	if v, ok := msg["muted"].(bool); ok {
+		muted = v
+	}
+
+	s.mutex.Lock()
+	if muted {
+		s.mutedRooms[room] = true
+	} else {
+		delete(s.mutedRooms, room)
+	}
+	s.mutex.Unlock()
+}
+
+func (s *SnapdropServer) handleListRoomsMessage(peer *Peer, msg map[string]interface{}) {
+	if !s.requireRole(peer, RoleOperator) {
+		s.sendPermissionDenied(peer, "list-rooms")
+		return
+	}
+
+	rooms := s.store.Rooms()
+
+	s.mutex.RLock()
+	snapshot := make([]map[string]interface{}, 0, len(rooms)+len(s.codedRooms))
+	for room, count := range rooms {
+		snapshot = append(snapshot, map[string]interface{}{
+			"room":  room,
+			"kind":  "ip",
+			"peers": count,
+			"muted": s.mutedRooms[room],
+		})
+	}
+	for code, room := range s.codedRooms {
+		snapshot = append(snapshot, map[string]interface{}{
+			"room":  code,
+			"kind":  "coded",
+			"peers": len(room.Peers),
+			"muted": s.mutedRooms[code],
+		})
+	}
+	s.mutex.RUnlock()
+
+	s.send(peer, map[string]interface{}{
+		"type":  "rooms",
+		"rooms": snapshot,
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Role identifies a peer's permission level.
+type Role string
+
+const (
+	RoleGuest    Role = "guest"
+	RoleOperator Role = "operator"
+)
+
+// resolveOperatorToken returns the static bearer token operators can present
+// instead of a signed JWT, configured via OPERATOR_TOKEN.
+func resolveOperatorToken() string {
+	return os.Getenv("OPERATOR_TOKEN")
+}
+
+// resolveJWTSecret returns the HS256 secret used to verify operator JWTs,
+// configured via OPERATOR_JWT_SECRET.
+func resolveJWTSecret() []byte {
+	return []byte(os.Getenv("OPERATOR_JWT_SECRET"))
+}
+
+// authenticateRole inspects the Authorization header and returns the role
+// the connecting peer should be granted: operator if a valid static token or
+// signed JWT is presented, guest otherwise.
+func authenticateRole(r *http.Request) Role {
+	token := bearerToken(r)
+	if token == "" {
+		return RoleGuest
+	}
+
+	if staticToken := resolveOperatorToken(); staticToken != "" && token == staticToken {
+		return RoleOperator
+	}
+
+	if secret := resolveJWTSecret(); len(secret) > 0 && isValidOperatorJWT(token, secret) {
+		return RoleOperator
+	}
+
+	return RoleGuest
+}
+
+func bearerToken(r *http.Request) string {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(header, prefix)
+}
+
+func isValidOperatorJWT(token string, secret []byte) bool {
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return secret, nil
+	})
+	return err == nil && parsed.Valid
+}
+
+// requireRole reports whether peer is permitted to perform an action that
+// needs at least the given role. Operators satisfy any requirement.
+func (s *SnapdropServer) requireRole(peer *Peer, role Role) bool {
+	if role == RoleGuest {
+		return true
+	}
+	return peer.Role == RoleOperator
+}
+
+func (s *SnapdropServer) sendPermissionDenied(peer *Peer, action string) {
+	s.send(peer, map[string]interface{}{
+		"type":   "permission-denied",
+		"action": action,
+	})
+}
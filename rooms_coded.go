@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/rand"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// roomCodeLength is the length of a generated room code.
+	roomCodeLength = 6
+	// roomCodeAlphabet excludes visually ambiguous characters (0/O, 1/I/L).
+	roomCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	// defaultRoomCodeTTL is how long a coded room stays joinable after creation.
+	defaultRoomCodeTTL = 10 * time.Minute
+	// roomJanitorInterval is how often the background sweep runs.
+	roomJanitorInterval = 1 * time.Minute
+	// roomEmptyGracePeriod keeps a just-created room around long enough for
+	// its creator to actually join before the janitor reaps it as "empty".
+	roomEmptyGracePeriod = roomJanitorInterval
+)
+
+// codedRoom is a named room joined via a short human-readable code instead
+// of IP-based grouping, for peers who can't share an IP (different NATs).
+type codedRoom struct {
+	Code      string
+	Peers     map[string]*Peer
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// resolveRoomCodeTTL reads ROOM_CODE_TTL (a Go duration string, e.g. "15m"),
+// falling back to defaultRoomCodeTTL.
+func resolveRoomCodeTTL() time.Duration {
+	if v := os.Getenv("ROOM_CODE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return defaultRoomCodeTTL
+}
+
+func generateRoomCode() (string, error) {
+	code := make([]byte, roomCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(roomCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = roomCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// createCodedRoomLocked allocates a fresh room with a unique code. Callers
+// must hold s.mutex for writing.
+func (s *SnapdropServer) createCodedRoomLocked() (*codedRoom, error) {
+	for {
+		code, err := generateRoomCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := s.codedRooms[code]; exists {
+			continue
+		}
+
+		now := time.Now()
+		room := &codedRoom{
+			Code:      code,
+			Peers:     make(map[string]*Peer),
+			CreatedAt: now,
+			ExpiresAt: now.Add(s.roomCodeTTL),
+		}
+		s.codedRooms[code] = room
+		roomsActive.Inc()
+		return room, nil
+	}
+}
+
+func (s *SnapdropServer) handleCreateRoom(c *gin.Context) {
+	s.mutex.Lock()
+	room, err := s.createCodedRoomLocked()
+	s.mutex.Unlock()
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate room code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":      room.Code,
+		"expiresAt": room.ExpiresAt,
+	})
+}
+
+func (s *SnapdropServer) handleCreateRoomMessage(peer *Peer, msg map[string]interface{}) {
+	s.mutex.Lock()
+	room, err := s.createCodedRoomLocked()
+	s.mutex.Unlock()
+
+	if err != nil {
+		s.send(peer, map[string]interface{}{"type": "room-error", "message": "failed to create room"})
+		return
+	}
+
+	s.send(peer, map[string]interface{}{
+		"type":      "room-created",
+		"code":      room.Code,
+		"expiresAt": room.ExpiresAt,
+	})
+}
+
+func (s *SnapdropServer) handleJoinRoomMessage(peer *Peer, msg map[string]interface{}) {
+	code, ok := msg["code"].(string)
+	if !ok {
+		return
+	}
+
+	s.mutex.Lock()
+
+	room, exists := s.codedRooms[code]
+	if !exists || time.Now().After(room.ExpiresAt) {
+		s.mutex.Unlock()
+		s.send(peer, map[string]interface{}{"type": "room-error", "code": code, "message": "room not found"})
+		return
+	}
+
+	for _, otherPeer := range room.Peers {
+		s.send(otherPeer, map[string]interface{}{
+			"type": "peer-joined",
+			"peer": peer.getInfo(),
+			"room": code,
+		})
+	}
+
+	otherPeers := make([]map[string]interface{}, 0, len(room.Peers))
+	for _, otherPeer := range room.Peers {
+		otherPeers = append(otherPeers, otherPeer.getInfo())
+	}
+
+	room.Peers[peer.ID] = peer
+	peer.RoomCodes = append(peer.RoomCodes, code)
+	peersConnected.WithLabelValues("coded").Inc()
+
+	s.mutex.Unlock()
+
+	s.send(peer, map[string]interface{}{
+		"type":  "room-peers",
+		"room":  code,
+		"peers": otherPeers,
+	})
+}
+
+func (s *SnapdropServer) handleLeaveRoomMessage(peer *Peer, msg map[string]interface{}) {
+	code, ok := msg["code"].(string)
+	if !ok {
+		return
+	}
+
+	s.mutex.Lock()
+	s.leaveCodedRoomLocked(peer, code)
+	s.mutex.Unlock()
+}
+
+// lookupCodedPeer looks for id among the coded rooms peer itself has
+// joined. Used as a fallback when a relay/moderation target isn't in the
+// sender's IP room -- the "different NATs" scenario coded rooms exist for.
+func (s *SnapdropServer) lookupCodedPeer(peer *Peer, id string) (*Peer, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, code := range peer.RoomCodes {
+		if room, exists := s.codedRooms[code]; exists {
+			if target, ok := room.Peers[id]; ok {
+				return target, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// leaveCodedRoomLocked removes peer from the coded room and notifies the
+// remaining occupants. Callers must hold s.mutex for writing.
+func (s *SnapdropServer) leaveCodedRoomLocked(peer *Peer, code string) {
+	room, exists := s.codedRooms[code]
+	if !exists {
+		return
+	}
+	if _, inRoom := room.Peers[peer.ID]; !inRoom {
+		return
+	}
+
+	delete(room.Peers, peer.ID)
+	peersConnected.WithLabelValues("coded").Dec()
+	for i, c := range peer.RoomCodes {
+		if c == code {
+			peer.RoomCodes = append(peer.RoomCodes[:i], peer.RoomCodes[i+1:]...)
+			break
+		}
+	}
+
+	for _, otherPeer := range room.Peers {
+		s.send(otherPeer, map[string]interface{}{
+			"type":   "peer-left",
+			"peerId": peer.ID,
+			"room":   code,
+		})
+	}
+}
+
+// runRoomJanitor periodically garbage-collects coded rooms that have expired
+// or gone empty, until the server starts shutting down.
+func (s *SnapdropServer) runRoomJanitor() {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(roomJanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.sweepCodedRooms()
+		}
+	}
+}
+
+func (s *SnapdropServer) sweepCodedRooms() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	reaped := 0
+	for code, room := range s.codedRooms {
+		expired := now.After(room.ExpiresAt)
+		abandoned := len(room.Peers) == 0 && now.Sub(room.CreatedAt) > roomEmptyGracePeriod
+		if expired || abandoned {
+			peersConnected.WithLabelValues("coded").Sub(float64(len(room.Peers)))
+			delete(s.codedRooms, code)
+			reaped++
+		}
+	}
+	if reaped > 0 {
+		roomsActive.Sub(float64(reaped))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// RoomStore abstracts where IP-room membership lives so the signaling logic
+// doesn't care whether it's talking to an in-process map (the default) or a
+// shared backend that lets multiple instances serve the same rooms.
+type RoomStore interface {
+	// Join adds peer to room and returns the peers already in it.
+	Join(room string, peer *Peer) []*Peer
+	// Leave removes the peer identified by peerID from room and returns the
+	// peers left behind (on this instance).
+	Leave(room string, peerID string) []*Peer
+	// Peers returns a snapshot of every peer known to be in room, including
+	// ones connected to other instances.
+	Peers(room string) []*Peer
+	// Lookup finds a peer connected to *this* instance. Implementations
+	// that span multiple instances only ever resolve local peers here,
+	// since only the owning instance can write to that peer's socket.
+	Lookup(room string, peerID string) (*Peer, bool)
+	// Rooms returns a snapshot of every known room and its peer count.
+	Rooms() map[string]int
+	// Subscribe starts forwarding messages addressed to peer (from Relay,
+	// or from another instance's Join/Leave) to deliver. The returned func
+	// stops delivery and releases any associated resources.
+	Subscribe(peer *Peer, deliver func(message map[string]interface{})) (unsubscribe func())
+	// Relay delivers message to peerID within room when that peer isn't
+	// reachable via a local Lookup. Single-instance stores have nowhere
+	// else to send it, so it's a no-op there.
+	Relay(room string, peerID string, message map[string]interface{})
+}
+
+// newRoomStore selects a RoomStore backend via ROOM_STORE ("memory" or
+// "redis", default "memory"), using REDIS_URL to connect when redis is
+// selected.
+func newRoomStore() (RoomStore, error) {
+	switch strings.ToLower(os.Getenv("ROOM_STORE")) {
+	case "redis":
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			redisURL = "redis://localhost:6379/0"
+		}
+		return newRedisRoomStore(redisURL)
+	default:
+		return newMemoryRoomStore(), nil
+	}
+}
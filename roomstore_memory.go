@@ -0,0 +1,90 @@
+package main
+
+import "sync"
+
+// memoryRoomStore is the default single-instance RoomStore backed by an
+// in-process map. Every peer it knows about is local, so Relay has no other
+// instance to forward to.
+type memoryRoomStore struct {
+	mu    sync.RWMutex
+	rooms map[string]map[string]*Peer
+}
+
+func newMemoryRoomStore() *memoryRoomStore {
+	return &memoryRoomStore{rooms: make(map[string]map[string]*Peer)}
+}
+
+func (m *memoryRoomStore) Join(room string, peer *Peer) []*Peer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.rooms[room] == nil {
+		m.rooms[room] = make(map[string]*Peer)
+	}
+
+	existing := make([]*Peer, 0, len(m.rooms[room]))
+	for _, p := range m.rooms[room] {
+		existing = append(existing, p)
+	}
+
+	m.rooms[room][peer.ID] = peer
+	return existing
+}
+
+func (m *memoryRoomStore) Leave(room string, peerID string) []*Peer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.rooms[room] == nil {
+		return nil
+	}
+
+	delete(m.rooms[room], peerID)
+
+	if len(m.rooms[room]) == 0 {
+		delete(m.rooms, room)
+		return nil
+	}
+
+	remaining := make([]*Peer, 0, len(m.rooms[room]))
+	for _, p := range m.rooms[room] {
+		remaining = append(remaining, p)
+	}
+	return remaining
+}
+
+func (m *memoryRoomStore) Peers(room string) []*Peer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	peers := make([]*Peer, 0, len(m.rooms[room]))
+	for _, p := range m.rooms[room] {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+func (m *memoryRoomStore) Lookup(room string, peerID string) (*Peer, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.rooms[room][peerID]
+	return p, ok
+}
+
+func (m *memoryRoomStore) Rooms() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot := make(map[string]int, len(m.rooms))
+	for room, peers := range m.rooms {
+		snapshot[room] = len(peers)
+	}
+	return snapshot
+}
+
+func (m *memoryRoomStore) Subscribe(peer *Peer, deliver func(message map[string]interface{})) func() {
+	return func() {}
+}
+
+func (m *memoryRoomStore) Relay(room string, peerID string, message map[string]interface{}) {}
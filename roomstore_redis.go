@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisRoomHashPrefix = "gosnapdrop:room:"
+	redisPeerChanPrefix = "gosnapdrop:peer:"
+	redisRoomChanPrefix = "gosnapdrop:presence:"
+)
+
+// redisRoomStore backs RoomStore with a Redis hash per room, shared by every
+// instance, plus pub/sub for cross-instance peer-joined/peer-left/relay
+// fan-out. Only peers actually connected to this instance have a live
+// *Peer.Socket here; peers owned by other instances are represented as
+// placeholder *Peer values decoded from the room hash, safe to pass to
+// getInfo() but not to send() (which no-ops on their nil writeCh).
+type redisRoomStore struct {
+	client     *redis.Client
+	ctx        context.Context
+	instanceID string
+
+	mu       sync.RWMutex
+	local    map[string]map[string]*Peer                     // room -> peerID -> local peer
+	deliver  map[string]func(message map[string]interface{}) // peerID -> callback for Relay/presence
+	roomSubs map[string]context.CancelFunc                   // room -> cancel for its presence subscription
+}
+
+func newRedisRoomStore(redisURL string) (*redisRoomStore, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &redisRoomStore{
+		client:     redis.NewClient(opts),
+		ctx:        context.Background(),
+		instanceID: generateUUID(),
+		local:      make(map[string]map[string]*Peer),
+		deliver:    make(map[string]func(message map[string]interface{})),
+		roomSubs:   make(map[string]context.CancelFunc),
+	}, nil
+}
+
+type redisPeerRecord struct {
+	ID           string   `json:"id"`
+	Name         PeerName `json:"name"`
+	RTCSupported bool     `json:"rtcSupported"`
+}
+
+func encodeRedisPeer(peer *Peer) string {
+	data, _ := json.Marshal(redisPeerRecord{ID: peer.ID, Name: peer.Name, RTCSupported: peer.RTCSupported})
+	return string(data)
+}
+
+func decodeRedisPeer(data string) *Peer {
+	var rec redisPeerRecord
+	if err := json.Unmarshal([]byte(data), &rec); err != nil {
+		return nil
+	}
+	return &Peer{ID: rec.ID, Name: rec.Name, RTCSupported: rec.RTCSupported}
+}
+
+type redisPresenceEvent struct {
+	Origin string                 `json:"origin"`
+	Type   string                 `json:"type"`
+	PeerID string                 `json:"peerId,omitempty"`
+	Peer   map[string]interface{} `json:"peer,omitempty"`
+}
+
+func (r *redisRoomStore) Join(room string, peer *Peer) []*Peer {
+	r.mu.Lock()
+	if r.local[room] == nil {
+		r.local[room] = make(map[string]*Peer)
+	}
+
+	existing := make([]*Peer, 0, len(r.local[room]))
+	localIDs := make(map[string]bool, len(r.local[room]))
+	for id, p := range r.local[room] {
+		existing = append(existing, p)
+		localIDs[id] = true
+	}
+
+	r.local[room][peer.ID] = peer
+
+	if _, subscribed := r.roomSubs[room]; !subscribed {
+		r.startRoomSubscription(room)
+	}
+	r.mu.Unlock()
+
+	r.client.HSet(r.ctx, redisRoomHashPrefix+room, peer.ID, encodeRedisPeer(peer))
+
+	remote, err := r.client.HGetAll(r.ctx, redisRoomHashPrefix+room).Result()
+	if err == nil {
+		for id, data := range remote {
+			if id == peer.ID || localIDs[id] {
+				continue
+			}
+			if p := decodeRedisPeer(data); p != nil {
+				existing = append(existing, p)
+			}
+		}
+	}
+
+	r.publishPresence(room, "peer-joined", peer)
+	return existing
+}
+
+func (r *redisRoomStore) Leave(room string, peerID string) []*Peer {
+	r.mu.Lock()
+	if r.local[room] != nil {
+		delete(r.local[room], peerID)
+	}
+
+	remaining := make([]*Peer, 0, len(r.local[room]))
+	for _, p := range r.local[room] {
+		remaining = append(remaining, p)
+	}
+
+	if len(r.local[room]) == 0 {
+		delete(r.local, room)
+		r.stopRoomSubscription(room)
+	}
+	r.mu.Unlock()
+
+	r.client.HDel(r.ctx, redisRoomHashPrefix+room, peerID)
+	r.publishPresence(room, "peer-left", &Peer{ID: peerID})
+
+	return remaining
+}
+
+func (r *redisRoomStore) Peers(room string) []*Peer {
+	r.mu.RLock()
+	peers := make([]*Peer, 0, len(r.local[room]))
+	seen := make(map[string]bool, len(r.local[room]))
+	for id, p := range r.local[room] {
+		peers = append(peers, p)
+		seen[id] = true
+	}
+	r.mu.RUnlock()
+
+	remote, err := r.client.HGetAll(r.ctx, redisRoomHashPrefix+room).Result()
+	if err != nil {
+		return peers
+	}
+	for id, data := range remote {
+		if seen[id] {
+			continue
+		}
+		if p := decodeRedisPeer(data); p != nil {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+func (r *redisRoomStore) Lookup(room string, peerID string) (*Peer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.local[room][peerID]
+	return p, ok
+}
+
+func (r *redisRoomStore) Rooms() map[string]int {
+	result := make(map[string]int)
+
+	iter := r.client.Scan(r.ctx, 0, redisRoomHashPrefix+"*", 0).Iterator()
+	for iter.Next(r.ctx) {
+		key := iter.Val()
+		count, err := r.client.HLen(r.ctx, key).Result()
+		if err != nil {
+			continue
+		}
+		result[strings.TrimPrefix(key, redisRoomHashPrefix)] = int(count)
+	}
+
+	return result
+}
+
+func (r *redisRoomStore) Subscribe(peer *Peer, deliver func(message map[string]interface{})) func() {
+	r.mu.Lock()
+	r.deliver[peer.ID] = deliver
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	sub := r.client.Subscribe(ctx, redisPeerChanPrefix+peer.ID)
+
+	go func() {
+		defer sub.Close()
+		for payload := range sub.Channel() {
+			var msg map[string]interface{}
+			if err := json.Unmarshal([]byte(payload.Payload), &msg); err != nil {
+				continue
+			}
+			deliver(msg)
+		}
+	}()
+
+	return func() {
+		cancel()
+		r.mu.Lock()
+		delete(r.deliver, peer.ID)
+		r.mu.Unlock()
+	}
+}
+
+func (r *redisRoomStore) Relay(room string, peerID string, message map[string]interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	r.client.Publish(r.ctx, redisPeerChanPrefix+peerID, data)
+}
+
+// startRoomSubscription begins listening for peer-joined/peer-left events
+// published by other instances for room. Callers must hold r.mu.
+func (r *redisRoomStore) startRoomSubscription(room string) {
+	ctx, cancel := context.WithCancel(r.ctx)
+	sub := r.client.Subscribe(ctx, redisRoomChanPrefix+room)
+	r.roomSubs[room] = cancel
+	go r.pumpPresence(room, sub)
+}
+
+// stopRoomSubscription cancels the presence subscription for room. Callers
+// must hold r.mu.
+func (r *redisRoomStore) stopRoomSubscription(room string) {
+	if cancel, ok := r.roomSubs[room]; ok {
+		cancel()
+		delete(r.roomSubs, room)
+	}
+}
+
+func (r *redisRoomStore) pumpPresence(room string, sub *redis.PubSub) {
+	defer sub.Close()
+
+	for payload := range sub.Channel() {
+		var evt redisPresenceEvent
+		if err := json.Unmarshal([]byte(payload.Payload), &evt); err != nil {
+			continue
+		}
+		if evt.Origin == r.instanceID {
+			// Already delivered synchronously to our own peers by Join/Leave.
+			continue
+		}
+
+		var msg map[string]interface{}
+		switch evt.Type {
+		case "peer-joined":
+			msg = map[string]interface{}{"type": "peer-joined", "peer": evt.Peer}
+		case "peer-left":
+			msg = map[string]interface{}{"type": "peer-left", "peerId": evt.PeerID}
+		default:
+			continue
+		}
+
+		r.mu.RLock()
+		delivers := make([]func(map[string]interface{}), 0, len(r.local[room]))
+		for peerID := range r.local[room] {
+			if d, ok := r.deliver[peerID]; ok {
+				delivers = append(delivers, d)
+			}
+		}
+		r.mu.RUnlock()
+
+		for _, deliver := range delivers {
+			deliver(msg)
+		}
+	}
+}
+
+func (r *redisRoomStore) publishPresence(room, eventType string, peer *Peer) {
+	evt := redisPresenceEvent{Origin: r.instanceID, Type: eventType, PeerID: peer.ID}
+	if eventType == "peer-joined" {
+		evt.Peer = peer.getInfo()
+	}
+
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return
+	}
+	r.client.Publish(r.ctx, redisRoomChanPrefix+room, data)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// resolveShutdownTimeout reads SHUTDOWN_TIMEOUT (a Go duration string, e.g.
+// "45s"), falling back to defaultShutdownTimeout.
+func resolveShutdownTimeout() time.Duration {
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return defaultShutdownTimeout
+}
+
+// Shutdown drains the server: it stops accepting new WebSocket upgrades,
+// tells every connected peer to reconnect, tears down their sockets, and
+// waits for their goroutines (tracked in s.wg) to finish or ctx to expire.
+// Safe to drive deterministically from tests.
+func (s *SnapdropServer) Shutdown(ctx context.Context) error {
+	s.shuttingDown.Store(true)
+
+	for _, peer := range s.allLocalPeers() {
+		s.send(peer, map[string]interface{}{
+			"type":    "server-shutdown",
+			"message": "server is restarting, please reconnect shortly",
+		})
+	}
+
+	// Give writePumps a moment to flush the notification above before we
+	// close every socket out from under them.
+	time.Sleep(100 * time.Millisecond)
+	close(s.done)
+
+	waitCh := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(waitCh)
+	}()
+
+	select {
+	case <-waitCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// allLocalPeers returns every peer connected to this instance, across both
+// IP and coded rooms.
+func (s *SnapdropServer) allLocalPeers() []*Peer {
+	seen := make(map[string]bool)
+	var peers []*Peer
+
+	for room := range s.store.Rooms() {
+		for _, peer := range s.store.Peers(room) {
+			if peer.Socket == nil || seen[peer.ID] {
+				continue
+			}
+			seen[peer.ID] = true
+			peers = append(peers, peer)
+		}
+	}
+
+	return peers
+}
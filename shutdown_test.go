@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestPeer dials a real WebSocket connection against a throwaway
+// httptest server, wires the server side into s as a Peer the same way
+// handleWebSocket would, and returns a channel that closes once the
+// client observes the connection go away -- so Shutdown can be exercised
+// end to end without starting the full router.
+func newTestPeer(t *testing.T, s *SnapdropServer) <-chan struct{} {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	ready := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		peer := &Peer{
+			ID:      generateUUID(),
+			IP:      "127.0.0.1",
+			Socket:  conn,
+			Ctx:     peerContext("", "127.0.0.1", ""),
+			writeCh: make(chan interface{}, defaultSendBufferSize),
+			kill:    make(chan struct{}),
+		}
+
+		s.wg.Add(1)
+		go s.writePump(peer)
+		s.store.Join(peer.IP, peer)
+		close(ready)
+	}))
+	t.Cleanup(srv.Close)
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+	client, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	<-ready
+
+	closed := make(chan struct{})
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				break
+			}
+		}
+		close(closed)
+	}()
+
+	return closed
+}
+
+func TestShutdownDrainsPeersWithinTimeout(t *testing.T) {
+	s := &SnapdropServer{
+		store:      newMemoryRoomStore(),
+		codedRooms: make(map[string]*codedRoom),
+		mutedRooms: make(map[string]bool),
+		done:       make(chan struct{}),
+	}
+
+	first := newTestPeer(t, s)
+	second := newTestPeer(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown did not drain before the timeout: %v", err)
+	}
+
+	for _, closed := range []<-chan struct{}{first, second} {
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("peer socket was never closed")
+		}
+	}
+}
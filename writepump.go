@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait = 10 * time.Second // time allowed to write a message to the peer
+	pongWait  = 60 * time.Second // time allowed to read the next message from the peer
+
+	// defaultSendBufferSize is the writeCh high-watermark: once a peer's
+	// outbound queue is this full, it's treated as slow/dead.
+	defaultSendBufferSize = 16
+)
+
+// writePump is the single goroutine allowed to write to peer.Socket.
+// gorilla/websocket forbids concurrent writers, so every outbound message
+// -- relays, presence, pings -- flows through peer.writeCh instead of
+// calling WriteJSON directly from whichever goroutine produced it.
+func (s *SnapdropServer) writePump(peer *Peer) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(pongWait * 9 / 10)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message := <-peer.writeCh:
+			peer.Socket.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := peer.Socket.WriteJSON(message); err != nil {
+				sendErrorsTotal.Inc()
+				logFrom(peer.Ctx).Error("send failed", "error", err)
+				peer.Socket.Close()
+				return
+			}
+
+		case <-ticker.C:
+			peer.Socket.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := peer.Socket.WriteJSON(map[string]string{"type": "ping"}); err != nil {
+				sendErrorsTotal.Inc()
+				logFrom(peer.Ctx).Error("ping failed", "error", err)
+				peer.Socket.Close()
+				return
+			}
+
+		case <-peer.kill:
+			code := peer.closeCode
+			if code == 0 {
+				code = websocket.CloseNormalClosure
+			}
+			peer.Socket.SetWriteDeadline(time.Now().Add(writeWait))
+			peer.Socket.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, peer.closeReason))
+			peer.Socket.Close()
+			return
+
+		case <-s.done:
+			peer.Socket.SetWriteDeadline(time.Now().Add(writeWait))
+			peer.Socket.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server shutting down"))
+			peer.Socket.Close()
+			return
+		}
+	}
+}
+
+// killPeer tears peer's connection down, sending a WS close frame with the
+// given code and reason before closing the socket. Safe to call more than
+// once or concurrently; only the first call's code/reason take effect.
+func (peer *Peer) killPeer(code int, reason string) {
+	peer.killOnce.Do(func() {
+		peer.closeCode = code
+		peer.closeReason = reason
+		close(peer.kill)
+	})
+}
+
+// resolveSendBufferSize reads SEND_BUFFER_SIZE (a peer's outbound queue
+// high-watermark), falling back to defaultSendBufferSize.
+func resolveSendBufferSize() int {
+	if v := os.Getenv("SEND_BUFFER_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultSendBufferSize
+}